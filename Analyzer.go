@@ -0,0 +1,176 @@
+package searcher
+
+import(
+	"tokenizer"
+	"bufio"
+	"os"
+	"strings"
+	"unicode"
+)
+
+//-----------------------------------------------------------------------------
+// Analyzer / TokenFilter
+//-----------------------------------------------------------------------------
+// Analyze turns text into normalized term strings rather than raw
+// tokenizer.Token values: TokenFilters need to rewrite (lowercase/strip
+// marks), drop (stop words) and even multiply (n-grams) tokens, and
+// tokenizer.Token exposes no public constructor for filters to build new
+// ones with. AddIndexes and Query both route through the same Analyzer so
+// index-time and query-time terms stay consistent.
+//-----------------------------------------------------------------------------
+type Analyzer interface {
+	Analyze(text string) []string
+}
+
+// TokenFilter transforms a list of terms produced by tokenizing raw text.
+// Filters are chained in order: each one receives the previous filter's output.
+type TokenFilter interface {
+	Filter(terms []string) []string
+}
+
+// chainAnalyzer tokenizes text with the Thai tokenizer, then runs the
+// resulting terms through a chain of TokenFilters.
+type chainAnalyzer struct {
+	tokenizer *tokenizer.LongLexto
+	filters []TokenFilter
+}
+
+func newAnalyzer(tok *tokenizer.LongLexto, filters []TokenFilter) *chainAnalyzer {
+	return &chainAnalyzer{tokenizer : tok, filters : filters}
+}
+
+func (a *chainAnalyzer) Analyze(text string) []string {
+	a.tokenizer.SetText(text)
+	terms := []string{}
+
+	for a.tokenizer.HasNext() {
+		token := a.tokenizer.Next()
+		if token.IsSpace() || token.IsHTML() {
+			continue
+		}
+		terms = append(terms, token.GetText())
+	}
+
+	for _, filter := range a.filters {
+		terms = filter.Filter(terms)
+	}
+	return terms
+}
+
+// DefaultFilters returns the filter chain used when Setup/Setup_db_session
+// are called without an explicit one: lowercasing plus a StopWordFilter whose
+// word list is empty, so no stop words are removed by default.
+func DefaultFilters() []TokenFilter {
+	return []TokenFilter{&LowercaseFilter{}, &StopWordFilter{words : map[string]bool{}}}
+}
+
+//-----------------------------------------------------------------------------
+// LowercaseFilter
+//-----------------------------------------------------------------------------
+type LowercaseFilter struct{}
+
+func (f *LowercaseFilter) Filter(terms []string) []string {
+	result := make([]string, len(terms))
+	for i, term := range terms {
+		result[i] = strings.ToLower(term)
+	}
+	return result
+}
+
+//-----------------------------------------------------------------------------
+// StopWordFilter
+//-----------------------------------------------------------------------------
+type StopWordFilter struct {
+	words map[string]bool
+}
+
+// NewStopWordFilter loads one stop word per line from path, the same format
+// used by the Go stemmer example's stop word list.
+func NewStopWordFilter(path string) (*StopWordFilter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	words := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		word := strings.TrimSpace(scanner.Text())
+		if word == "" {
+			continue
+		}
+		words[word] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return &StopWordFilter{words : words}, nil
+}
+
+func (f *StopWordFilter) Filter(terms []string) []string {
+	result := make([]string, 0, len(terms))
+	for _, term := range terms {
+		if !f.words[term] {
+			result = append(result, term)
+		}
+	}
+	return result
+}
+
+//-----------------------------------------------------------------------------
+// NormalizeFilter
+//-----------------------------------------------------------------------------
+// NormalizeFilter strips Thai tone/vowel marks (Unicode non-spacing marks)
+// so visually-equivalent spellings with differing diacritics collapse to the
+// same index term.
+type NormalizeFilter struct{}
+
+func (f *NormalizeFilter) Filter(terms []string) []string {
+	result := make([]string, len(terms))
+	for i, term := range terms {
+		result[i] = stripMarks(term)
+	}
+	return result
+}
+
+func stripMarks(term string) string {
+	stripped := make([]rune, 0, len(term))
+	for _, r := range term {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		stripped = append(stripped, r)
+	}
+	return string(stripped)
+}
+
+//-----------------------------------------------------------------------------
+// NGramFilter
+//-----------------------------------------------------------------------------
+// NGramFilter emits overlapping n-grams of each term (in addition to terms
+// shorter than n, which pass through unchanged) so substring matching works
+// on compound Thai words the tokenizer otherwise treats as a single token.
+type NGramFilter struct {
+	n int
+}
+
+func NewNGramFilter(n int) *NGramFilter {
+	return &NGramFilter{n : n}
+}
+
+func (f *NGramFilter) Filter(terms []string) []string {
+	result := make([]string, 0, len(terms))
+	for _, term := range terms {
+		runes := []rune(term)
+		if len(runes) < f.n {
+			result = append(result, term)
+			continue
+		}
+		for i := 0; i+f.n <= len(runes); i++ {
+			result = append(result, string(runes[i:i+f.n]))
+		}
+	}
+	return result
+}
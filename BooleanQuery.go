@@ -0,0 +1,543 @@
+package searcher
+
+import(
+	"fmt"
+	"strings"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//-----------------------------------------------------------------------------
+// Boolean Query Language
+//-----------------------------------------------------------------------------
+// A small recursive-descent parser/evaluator for queries such as:
+//   ไทย AND (อาหาร OR ขนม) NOT หวาน
+//   title:"ต้มยำกุ้ง"
+// The parser builds an AST of queryNode values; QueryBoolean evaluates it
+// against "inverted_index" to obtain a set of matching blog IDs, then hands
+// those IDs to the existing rank pipeline so results still come back TF-IDF
+// (or BM25) ordered.
+//
+// Grammar (AND binds "NOT" as an implicit "AND NOT", matching the example
+// above where NOT appears without a preceding AND):
+//   orExpr   := andExpr ('OR' andExpr)*
+//   andExpr  := primary (('AND' | 'NOT') primary)*
+//   primary  := '(' orExpr ')' | FIELD ':' (TERM | PHRASE) | PHRASE | TERM
+//-----------------------------------------------------------------------------
+type queryNode interface {
+	eval(data *DBConnector) (map[bson.ObjectId]bool, error)
+}
+
+type termNode struct {
+	term string
+}
+
+type fieldTermNode struct {
+	field string
+	term string
+}
+
+type phraseNode struct {
+	text string
+}
+
+type fieldPhraseNode struct {
+	field string
+	text string
+}
+
+type andNode struct {
+	left queryNode
+	right queryNode
+}
+
+type orNode struct {
+	left queryNode
+	right queryNode
+}
+
+// notNode represents "left AND NOT right", matched by an unqualified "NOT"
+// appearing after another term in an andExpr chain.
+type notNode struct {
+	left queryNode
+	right queryNode
+}
+
+func (n *termNode) eval(data *DBConnector) (map[bson.ObjectId]bool, error) {
+	// Use the analyzed term, not n.term itself, so this matches against
+	// terms exactly as stored in inverted_index (e.g. lowercased)
+	terms := data.tokenizeTerms(n.term)
+	if len(terms) == 0 {
+		return map[bson.ObjectId]bool{}, nil
+	}
+	if len(terms) == 1 {
+		return data.termBlogSet(terms[0], "")
+	}
+	return data.phraseBlogSet(terms, "")
+}
+
+func (n *fieldTermNode) eval(data *DBConnector) (map[bson.ObjectId]bool, error) {
+	// Use the analyzed term, not n.term itself, so this matches against
+	// terms exactly as stored in inverted_index (e.g. lowercased)
+	terms := data.tokenizeTerms(n.term)
+	if len(terms) == 0 {
+		return map[bson.ObjectId]bool{}, nil
+	}
+	if len(terms) == 1 {
+		return data.termBlogSet(terms[0], n.field)
+	}
+	return data.phraseBlogSet(terms, n.field)
+}
+
+func (n *phraseNode) eval(data *DBConnector) (map[bson.ObjectId]bool, error) {
+	return data.phraseBlogSet(data.tokenizeTerms(n.text), "")
+}
+
+func (n *fieldPhraseNode) eval(data *DBConnector) (map[bson.ObjectId]bool, error) {
+	return data.phraseBlogSet(data.tokenizeTerms(n.text), n.field)
+}
+
+func (n *andNode) eval(data *DBConnector) (map[bson.ObjectId]bool, error) {
+	left, err := n.left.eval(data)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(data)
+	if err != nil {
+		return nil, err
+	}
+	return intersectSets(left, right), nil
+}
+
+func (n *orNode) eval(data *DBConnector) (map[bson.ObjectId]bool, error) {
+	left, err := n.left.eval(data)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(data)
+	if err != nil {
+		return nil, err
+	}
+	return unionSets(left, right), nil
+}
+
+func (n *notNode) eval(data *DBConnector) (map[bson.ObjectId]bool, error) {
+	left, err := n.left.eval(data)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(data)
+	if err != nil {
+		return nil, err
+	}
+	return differenceSets(left, right), nil
+}
+
+//-----------------------------------------------------------------------------
+// Set helpers (Internal use)
+//-----------------------------------------------------------------------------
+func toSet(ids []bson.ObjectId) map[bson.ObjectId]bool {
+	set := make(map[bson.ObjectId]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
+
+func setToSlice(set map[bson.ObjectId]bool) []bson.ObjectId {
+	ids := make([]bson.ObjectId, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func intersectSets(a map[bson.ObjectId]bool, b map[bson.ObjectId]bool) map[bson.ObjectId]bool {
+	result := make(map[bson.ObjectId]bool)
+	for id := range a {
+		if b[id] {
+			result[id] = true
+		}
+	}
+	return result
+}
+
+func unionSets(a map[bson.ObjectId]bool, b map[bson.ObjectId]bool) map[bson.ObjectId]bool {
+	result := make(map[bson.ObjectId]bool, len(a) + len(b))
+	for id := range a {
+		result[id] = true
+	}
+	for id := range b {
+		result[id] = true
+	}
+	return result
+}
+
+func differenceSets(a map[bson.ObjectId]bool, b map[bson.ObjectId]bool) map[bson.ObjectId]bool {
+	result := make(map[bson.ObjectId]bool)
+	for id := range a {
+		if !b[id] {
+			result[id] = true
+		}
+	}
+	return result
+}
+
+//-----------------------------------------------------------------------------
+// DBConnector Private Methods (Boolean evaluation)
+//-----------------------------------------------------------------------------
+// 1. tokenizeTerms: Internal use
+// Runs text through the same analyzer AddIndexes used, so leaf nodes of the
+// AST match against terms exactly as stored in inverted_index.
+func (data *DBConnector) tokenizeTerms(text string) []string {
+	return data.analyzer.Analyze(text)
+}
+//-----------------------------------------------------------------------------
+// 2. termBlogSet: Internal use
+// Fetches the set of blogs containing a single term, optionally restricted
+// to a field (title/content/tag). A term's row carries positions per field
+// it occurred in, so restricting to a field is a check that that field's
+// key is present rather than an equality match against a single stored field.
+func (data *DBConnector) termBlogSet(term string, field string) (map[bson.ObjectId]bool, error) {
+	query := bson.M{"term" : term}
+	if field != "" {
+		query["positions." + field] = bson.M{"$exists" : true}
+	}
+
+	ids := []bson.ObjectId{}
+	err := data.termTable.Find(query).Distinct("blog_id", &ids)
+	if err != nil {
+		return nil, err
+	}
+	return toSet(ids), nil
+}
+//-----------------------------------------------------------------------------
+// 3. phraseBlogSet: Internal use
+// Fetches the set of blogs containing every term of a phrase at consecutive
+// token positions within the same field, optionally restricted to a
+// specific field. When no field is given, a match in any single field
+// (title, content, or a tag) counts — the phrase's terms must still be
+// adjacent within that one field, never spanning a field boundary.
+func (data *DBConnector) phraseBlogSet(terms []string, field string) (map[bson.ObjectId]bool, error) {
+	if len(terms) == 0 {
+		return map[bson.ObjectId]bool{}, nil
+	}
+
+	// 1. Narrow down to blogs which contain at least one of the phrase's terms
+	candidates := data.retrieve(terms)
+	result := make(map[bson.ObjectId]bool)
+
+	// 2. For each candidate, check that every term occurs at consecutive positions
+	for _, blogID := range candidates {
+		query := bson.M{"term" : bson.M{"$in" : terms}, "blog_id" : blogID}
+		if field != "" {
+			query["positions." + field] = bson.M{"$exists" : true}
+		}
+
+		rows := []Inverted_Index{}
+		data.termTable.Find(query).All(&rows)
+
+		byTerm := make(map[string]map[string][]int)
+		for _, row := range rows {
+			byTerm[row.Term] = row.Positions
+		}
+
+		if phraseMatchesAnyField(terms, byTerm, field) {
+			result[blogID] = true
+		}
+	}
+	return result, nil
+}
+//-----------------------------------------------------------------------------
+// 4. phraseMatchesAnyField: Internal use
+// Tries each candidate field (just "field" if given, otherwise every field
+// present in byTerm) and reports whether the phrase's terms are adjacent
+// within at least one of them.
+func phraseMatchesAnyField(terms []string, byTerm map[string]map[string][]int, field string) bool {
+	for _, candidate := range candidateFields(byTerm, field) {
+		positions := make(map[string][]int)
+		for _, term := range terms {
+			positions[term] = byTerm[term][candidate]
+		}
+		if phraseMatches(terms, positions) {
+			return true
+		}
+	}
+	return false
+}
+
+// candidateFields returns the fields a phrase match should be tried against:
+// just "field" if the caller restricted to one, otherwise the union of every
+// field any of the candidate rows carry positions for.
+func candidateFields(byTerm map[string]map[string][]int, field string) []string {
+	if field != "" {
+		return []string{field}
+	}
+	seen := make(map[string]bool)
+	fields := []string{}
+	for _, positions := range byTerm {
+		for f := range positions {
+			if !seen[f] {
+				seen[f] = true
+				fields = append(fields, f)
+			}
+		}
+	}
+	return fields
+}
+//-----------------------------------------------------------------------------
+// 5. phraseMatches: Internal use
+// Reports whether there exists a starting position for terms[0] such that
+// terms[1], terms[2], ... each occur at the immediately following position.
+func phraseMatches(terms []string, positions map[string][]int) bool {
+	for _, start := range positions[terms[0]] {
+		matched := true
+		for offset, term := range terms[1:] {
+			if !containsPosition(positions[term], start + offset + 1) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func containsPosition(positions []int, target int) bool {
+	for _, pos := range positions {
+		if pos == target {
+			return true
+		}
+	}
+	return false
+}
+//-----------------------------------------------------------------------------
+// 6. collectTerms: Internal use
+// Flattens every leaf term/phrase in the AST into a single term list, used
+// to rank the Boolean-matched blogs with the existing TF-IDF/BM25 pipeline.
+// Leaves on the excluded (right) side of a NOT are skipped.
+func collectTerms(data *DBConnector, node queryNode) []string {
+	terms := []string{}
+	switch n := node.(type) {
+	case *termNode:
+		terms = append(terms, data.tokenizeTerms(n.term)...)
+	case *fieldTermNode:
+		terms = append(terms, data.tokenizeTerms(n.term)...)
+	case *phraseNode:
+		terms = append(terms, data.tokenizeTerms(n.text)...)
+	case *fieldPhraseNode:
+		terms = append(terms, data.tokenizeTerms(n.text)...)
+	case *andNode:
+		terms = append(terms, collectTerms(data, n.left)...)
+		terms = append(terms, collectTerms(data, n.right)...)
+	case *orNode:
+		terms = append(terms, collectTerms(data, n.left)...)
+		terms = append(terms, collectTerms(data, n.right)...)
+	case *notNode:
+		terms = append(terms, collectTerms(data, n.left)...)
+	}
+	return terms
+}
+
+//-----------------------------------------------------------------------------
+// Parser
+//-----------------------------------------------------------------------------
+type boolToken struct {
+	kind string
+	text string
+	field string
+}
+
+// lexBoolean splits a query string into tokens, keeping parentheses and
+// quoted phrases intact and recognizing the AND/OR/NOT keywords.
+func lexBoolean(expr string) []boolToken {
+	tokens := []boolToken{}
+	runes := []rune(expr)
+	i := 0
+
+	for i < len(runes) {
+		r := runes[i]
+		switch {
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			i++
+		case r == '(':
+			tokens = append(tokens, boolToken{kind : "LPAREN"})
+			i++
+		case r == ')':
+			tokens = append(tokens, boolToken{kind : "RPAREN"})
+			i++
+		case r == '"':
+			j := i + 1
+			for j < len(runes) && runes[j] != '"' {
+				j++
+			}
+			tokens = append(tokens, boolToken{kind : "PHRASE", text : string(runes[i+1 : j])})
+			if j < len(runes) {
+				j++
+			}
+			i = j
+		default:
+			j := i
+			for j < len(runes) && runes[j] != ' ' && runes[j] != '\t' && runes[j] != '\n' && runes[j] != '(' && runes[j] != ')' && runes[j] != '"' {
+				j++
+			}
+			word := string(runes[i:j])
+			i = j
+
+			switch word {
+			case "AND":
+				tokens = append(tokens, boolToken{kind : "AND"})
+			case "OR":
+				tokens = append(tokens, boolToken{kind : "OR"})
+			case "NOT":
+				tokens = append(tokens, boolToken{kind : "NOT"})
+			default:
+				if idx := strings.Index(word, ":"); idx > 0 {
+					field := word[:idx]
+					rest := word[idx+1:]
+					if rest == "" {
+						tokens = append(tokens, boolToken{kind : "FIELD_PREFIX", text : field})
+					} else {
+						tokens = append(tokens, boolToken{kind : "FIELD", field : field, text : rest})
+					}
+				} else {
+					tokens = append(tokens, boolToken{kind : "TERM", text : word})
+				}
+			}
+		}
+	}
+	return tokens
+}
+
+type boolParser struct {
+	tokens []boolToken
+	pos int
+}
+
+func (p *boolParser) peek() boolToken {
+	if p.pos >= len(p.tokens) {
+		return boolToken{kind : "EOF"}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *boolParser) next() boolToken {
+	tok := p.peek()
+	p.pos++
+	return tok
+}
+
+// orExpr := andExpr ('OR' andExpr)*
+func (p *boolParser) orExpr() (queryNode, error) {
+	left, err := p.andExpr()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == "OR" {
+		p.next()
+		right, err := p.andExpr()
+		if err != nil {
+			return nil, err
+		}
+		left = &orNode{left : left, right : right}
+	}
+	return left, nil
+}
+
+// andExpr := primary (('AND' | 'NOT') primary)*
+func (p *boolParser) andExpr() (queryNode, error) {
+	left, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.peek().kind {
+		case "AND":
+			p.next()
+			right, err := p.primary()
+			if err != nil {
+				return nil, err
+			}
+			left = &andNode{left : left, right : right}
+		case "NOT":
+			p.next()
+			right, err := p.primary()
+			if err != nil {
+				return nil, err
+			}
+			left = &notNode{left : left, right : right}
+		default:
+			return left, nil
+		}
+	}
+}
+
+// primary := '(' orExpr ')' | FIELD_PREFIX (PHRASE | TERM) | FIELD | PHRASE | TERM
+func (p *boolParser) primary() (queryNode, error) {
+	tok := p.peek()
+	switch tok.kind {
+	case "LPAREN":
+		p.next()
+		node, err := p.orExpr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != "RPAREN" {
+			return nil, fmt.Errorf("boolean query: expected ')'")
+		}
+		p.next()
+		return node, nil
+	case "PHRASE":
+		p.next()
+		return &phraseNode{text : tok.text}, nil
+	case "FIELD_PREFIX":
+		p.next()
+		next := p.peek()
+		switch next.kind {
+		case "PHRASE":
+			p.next()
+			return &fieldPhraseNode{field : tok.text, text : next.text}, nil
+		case "TERM":
+			p.next()
+			return &fieldTermNode{field : tok.text, term : next.text}, nil
+		default:
+			return nil, fmt.Errorf("boolean query: expected a term or phrase after '%s:'", tok.text)
+		}
+	case "FIELD":
+		p.next()
+		return &fieldTermNode{field : tok.field, term : tok.text}, nil
+	case "TERM":
+		p.next()
+		return &termNode{term : tok.text}, nil
+	default:
+		return nil, fmt.Errorf("boolean query: unexpected token near position %d", p.pos)
+	}
+}
+
+//-----------------------------------------------------------------------------
+// DBConnector Methods
+//-----------------------------------------------------------------------------
+// 1. QueryBoolean:
+// I   - Parse expr into an AST of And/Or/Not/Term/Phrase/FieldTerm nodes.
+// II  - Evaluate the AST against inverted_index to get matching blog IDs.
+// III - Rank the matched blogs with the existing TF-IDF/BM25 pipeline.
+func (data *DBConnector) QueryBoolean(expr string) ([]bson.ObjectId, error) {
+	parser := &boolParser{tokens : lexBoolean(expr)}
+	ast, err := parser.orExpr()
+	if err != nil {
+		return nil, err
+	}
+	if parser.peek().kind != "EOF" {
+		return nil, fmt.Errorf("boolean query: unexpected trailing input near position %d", parser.pos)
+	}
+
+	matched, err := ast.eval(data)
+	if err != nil {
+		return nil, err
+	}
+
+	blogs := setToSlice(matched)
+	terms := collectTerms(data, ast)
+	return data.rank(terms, blogs), nil
+}
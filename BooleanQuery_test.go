@@ -0,0 +1,177 @@
+package searcher
+
+import(
+	"strings"
+	"testing"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// whitespaceAnalyzer is a minimal Analyzer stub so these tests can exercise
+// DBConnector methods that only need tokenization (tokenizeTerms,
+// collectTerms), without depending on the real dictionary-backed tokenizer.
+type whitespaceAnalyzer struct{}
+
+func (whitespaceAnalyzer) Analyze(text string) []string {
+	return strings.Fields(text)
+}
+
+func TestLexBooleanKeywordsAndParens(t *testing.T) {
+	tokens := lexBoolean("a AND (b OR c) NOT d")
+	kinds := make([]string, len(tokens))
+	for i, tok := range tokens {
+		kinds[i] = tok.kind
+	}
+
+	expected := []string{"TERM", "AND", "LPAREN", "TERM", "OR", "TERM", "RPAREN", "NOT", "TERM"}
+	if len(kinds) != len(expected) {
+		t.Fatalf("got %d tokens %v, want %d tokens %v", len(kinds), kinds, len(expected), expected)
+	}
+	for i := range expected {
+		if kinds[i] != expected[i] {
+			t.Errorf("token %d: got %q, want %q", i, kinds[i], expected[i])
+		}
+	}
+}
+
+func TestLexBooleanFieldPhrase(t *testing.T) {
+	tokens := lexBoolean(`title:"hot soup"`)
+	if len(tokens) != 2 || tokens[0].kind != "FIELD_PREFIX" || tokens[0].text != "title" {
+		t.Fatalf("unexpected tokens: %+v", tokens)
+	}
+	if tokens[1].kind != "PHRASE" || tokens[1].text != "hot soup" {
+		t.Fatalf("unexpected phrase token: %+v", tokens[1])
+	}
+}
+
+func TestLexBooleanFieldTerm(t *testing.T) {
+	tokens := lexBoolean("tag:dessert")
+	if len(tokens) != 1 || tokens[0].kind != "FIELD" || tokens[0].field != "tag" || tokens[0].text != "dessert" {
+		t.Fatalf("unexpected tokens: %+v", tokens)
+	}
+}
+
+func TestParseAndBindsTighterThanOr(t *testing.T) {
+	// "a AND b OR c" should parse as (a AND b) OR c
+	parser := &boolParser{tokens : lexBoolean("a AND b OR c")}
+	ast, err := parser.orExpr()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	or, ok := ast.(*orNode)
+	if !ok {
+		t.Fatalf("expected top-level *orNode, got %T", ast)
+	}
+	and, ok := or.left.(*andNode)
+	if !ok {
+		t.Fatalf("expected left side of OR to be *andNode, got %T", or.left)
+	}
+	if and.left.(*termNode).term != "a" || and.right.(*termNode).term != "b" {
+		t.Fatalf("unexpected AND operands: %+v", and)
+	}
+	if or.right.(*termNode).term != "c" {
+		t.Fatalf("unexpected OR right operand: %+v", or.right)
+	}
+}
+
+func TestParseTrailingNotIsAndNot(t *testing.T) {
+	// "a NOT b" (no explicit AND) should parse as a single "a AND NOT b"
+	parser := &boolParser{tokens : lexBoolean("a NOT b")}
+	ast, err := parser.orExpr()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	not, ok := ast.(*notNode)
+	if !ok {
+		t.Fatalf("expected *notNode, got %T", ast)
+	}
+	if not.left.(*termNode).term != "a" || not.right.(*termNode).term != "b" {
+		t.Fatalf("unexpected NOT operands: %+v", not)
+	}
+}
+
+func TestParseUnmatchedParenIsAnError(t *testing.T) {
+	parser := &boolParser{tokens : lexBoolean("(a AND b")}
+	if _, err := parser.orExpr(); err == nil {
+		t.Fatal("expected a parse error for an unmatched '('")
+	}
+}
+
+func TestParseDanglingFieldPrefixIsAnError(t *testing.T) {
+	parser := &boolParser{tokens : lexBoolean("title:")}
+	if _, err := parser.orExpr(); err == nil {
+		t.Fatal("expected a parse error for 'title:' with nothing after it")
+	}
+}
+
+func TestQueryBooleanRejectsTrailingInput(t *testing.T) {
+	data := &DBConnector{analyzer : whitespaceAnalyzer{}}
+	if _, err := data.QueryBoolean("a)"); err == nil {
+		t.Fatal("expected a parse error for a trailing unmatched ')'")
+	}
+}
+
+func TestPhraseMatchesAdjacentPositions(t *testing.T) {
+	positions := map[string][]int{
+		"hot": {0, 10},
+		"soup": {1},
+	}
+	if !phraseMatches([]string{"hot", "soup"}, positions) {
+		t.Fatal("expected adjacent terms to match as a phrase")
+	}
+}
+
+func TestPhraseMatchesRejectsNonAdjacentPositions(t *testing.T) {
+	positions := map[string][]int{
+		"hot": {0},
+		"soup": {5},
+	}
+	if phraseMatches([]string{"hot", "soup"}, positions) {
+		t.Fatal("expected non-adjacent terms not to match as a phrase")
+	}
+}
+
+func TestSetOperations(t *testing.T) {
+	a := toSet([]bson.ObjectId{"1", "2", "3"})
+	b := toSet([]bson.ObjectId{"2", "3", "4"})
+
+	inter := intersectSets(a, b)
+	if len(inter) != 2 || !inter["2"] || !inter["3"] {
+		t.Fatalf("unexpected intersection: %v", inter)
+	}
+
+	uni := unionSets(a, b)
+	if len(uni) != 4 {
+		t.Fatalf("unexpected union size: %d", len(uni))
+	}
+
+	diff := differenceSets(a, b)
+	if len(diff) != 1 || !diff["1"] {
+		t.Fatalf("unexpected difference: %v", diff)
+	}
+}
+
+func TestCollectTermsSkipsExcludedSideOfNot(t *testing.T) {
+	// collectTerms only descends into a notNode's left side: the excluded
+	// (right) side shouldn't contribute to ranking relevance.
+	data := &DBConnector{analyzer : whitespaceAnalyzer{}}
+	ast := &andNode{
+		left : &termNode{term : "thai"},
+		right : &notNode{
+			left : &orNode{left : &termNode{term : "rice"}, right : &termNode{term : "noodles"}},
+			right : &termNode{term : "sweet"},
+		},
+	}
+
+	expected := map[string]bool{"thai" : true, "rice" : true, "noodles" : true}
+	terms := collectTerms(data, ast)
+	if len(terms) != len(expected) {
+		t.Fatalf("got %d terms %v, want %d: %v", len(terms), terms, len(expected), expected)
+	}
+	for _, term := range terms {
+		if !expected[term] {
+			t.Errorf("unexpected term %q", term)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package searcher
+
+import(
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const(
+	// BUILDER_FLUSH_BATCH is how many blogs Add() accumulates in RAM before
+	// automatically Commit()-ing, when used for incremental batch updates.
+	BUILDER_FLUSH_BATCH = 1000
+	// BUILDER_BULK_CHUNK is the max number of documents per Mongo Bulk() call.
+	BUILDER_BULK_CHUNK = 1000
+)
+
+// Posting is one (blog, occurrence) pair accumulated in RAM for a term,
+// carrying everything newIndexes would otherwise have written per blog.
+// positions is keyed by field, same as termOccurrence, so a term's
+// occurrences in different fields never collide as "adjacent".
+type Posting struct {
+	blogID bson.ObjectId
+	occurrences int
+	positions map[string][]int
+}
+
+// PostingList is every Posting seen for one term, plus the running count of
+// distinct blogs it appeared in (its document frequency).
+type PostingList struct {
+	postings []Posting
+	documentFrequency int
+}
+
+//-----------------------------------------------------------------------------
+// Builder
+//-----------------------------------------------------------------------------
+// Builder accumulates postings, per-document Euclidean norms, and per-document
+// lengths entirely in memory, so bulk ingestion of N blogs costs O(N) Mongo
+// round-trips instead of the O(N*T) Find/Insert/Update calls AddIndexes
+// issues per blog (one per distinct token). AddIndexes still exists and
+// should be used for single-document updates; bulk ingestion should go
+// through a Builder instead.
+//-----------------------------------------------------------------------------
+type Builder struct {
+	data *DBConnector
+	postings map[string]*PostingList
+	norms map[bson.ObjectId]float64
+	lengths map[bson.ObjectId]int
+	docCount int
+	pending int
+	minDocFreq int
+	maxDocFreq int
+}
+
+// NewBuilder creates a Builder which will read blogs through, and flush
+// indexes to, the collections behind data.
+func NewBuilder(data *DBConnector) *Builder {
+	return &Builder{
+		data : data,
+		postings : make(map[string]*PostingList),
+		norms : make(map[bson.ObjectId]float64),
+		lengths : make(map[bson.ObjectId]int),
+	}
+}
+
+// SetDocFreqCutoffs drops terms whose document frequency falls outside
+// [min, max] at finalization time (0 disables a cutoff), letting callers
+// exclude excessively rare or common terms from the index.
+func (b *Builder) SetDocFreqCutoffs(min int, max int) {
+	b.minDocFreq = min
+	b.maxDocFreq = max
+}
+//-----------------------------------------------------------------------------
+// Builder Methods
+//-----------------------------------------------------------------------------
+// 1. Add:
+// Analyzes one blog and folds its terms into the in-memory postings, norms,
+// and lengths maps. Auto-commits every BUILDER_FLUSH_BATCH documents so
+// Add can also be used for incremental batch updates.
+func (b *Builder) Add(blog Blog) {
+	frequency := b.data.count_occurences(blog)
+	norm := euclidean_norm(frequency)
+	length := 0
+
+	for term, occurrence := range frequency {
+		length += occurrence.count
+
+		list, found := b.postings[term]
+		if !found {
+			list = &PostingList{}
+			b.postings[term] = list
+		}
+		list.postings = append(list.postings, Posting{
+			blogID : blog.Blog_id,
+			occurrences : occurrence.count,
+			positions : occurrence.positions,
+			})
+		list.documentFrequency += 1
+	}
+
+	b.norms[blog.Blog_id] = norm
+	b.lengths[blog.Blog_id] = length
+	b.docCount += 1
+	b.pending += 1
+
+	if b.pending >= BUILDER_FLUSH_BATCH {
+		b.Commit()
+	}
+}
+//-----------------------------------------------------------------------------
+// 2. BuildAll:
+// Streams every blog in the post collection through Add exactly once, then
+// commits whatever remains. Intended for bulk/initial indexing.
+func (b *Builder) BuildAll() {
+	blogs := []Blog{}
+	b.data.blogTable.Find(nil).All(&blogs)
+	for _, blog := range blogs {
+		b.Add(blog)
+	}
+	b.Commit()
+}
+//-----------------------------------------------------------------------------
+// 3. Commit:
+// Finalizes everything accumulated in RAM so far: computes correct IDF
+// (log(N/df)) per term and TF*IDF per posting, drops terms outside the
+// configured document-frequency cutoffs, then flushes term_weight,
+// inverted_index, and doc_stats to Mongo. Resets the in-memory maps
+// afterward so the same Builder can keep accumulating the next batch.
+//
+// A term's document frequency can span more than one batch (e.g. BuildAll
+// auto-commits every BUILDER_FLUSH_BATCH blogs), so term_weight is updated
+// by adding this batch's df onto whatever df is already persisted for the
+// term, rather than inserted unconditionally — otherwise a term seen across
+// N batches would end up with N separate (and each individually wrong,
+// partial-df) term_weight rows instead of one correct one.
+func (b *Builder) Commit() {
+	if len(b.postings) == 0 && len(b.lengths) == 0 {
+		return
+	}
+
+	totalBlogs, _ := b.data.blogTable.Find(nil).Count()
+
+	entries := []interface{}{}
+	existing := Term_Weight{}
+
+	for term, list := range b.postings {
+		df := list.documentFrequency
+		if b.minDocFreq > 0 && df < b.minDocFreq {
+			continue
+		}
+		if b.maxDocFreq > 0 && df > b.maxDocFreq {
+			continue
+		}
+
+		// Fold this batch's df onto whatever is already persisted for the term
+		err := b.data.weightTable.Find(bson.M{"term" : term}).One(&existing)
+		totalDf := df
+		if err == nil {
+			totalDf += existing.Total_blogs
+		}
+		idf := inverse_document_freq(totalDf, totalBlogs)
+
+		if err == nil {
+			b.data.weightTable.Update(
+				bson.M{"term" : term},
+				bson.M{"$set" : bson.M{"idf" : idf, "total_blogs" : totalDf}})
+		} else {
+			b.data.weightTable.Insert(&Term_Weight{Term : term, Total_blogs : totalDf, Idf : idf})
+		}
+
+		for _, posting := range list.postings {
+			tf := term_freq(posting.occurrences, b.norms[posting.blogID])
+			entries = append(entries, &Inverted_Index{
+				Term : term,
+				Blog_id : posting.blogID,
+				Tf : tf,
+				Tf_Idf : tf_idf(tf, idf),
+				Occurrences : posting.occurrences,
+				Positions : posting.positions,
+				})
+		}
+	}
+
+	docStats := make([]interface{}, 0, len(b.lengths))
+	for blogID, length := range b.lengths {
+		docStats = append(docStats, &Doc_Stats{Blog_id : blogID, Length : length})
+	}
+
+	bulkInsert(b.data.termTable, entries)
+	bulkInsert(b.data.docStatsTable, docStats)
+
+	b.postings = make(map[string]*PostingList)
+	b.norms = make(map[bson.ObjectId]float64)
+	b.lengths = make(map[bson.ObjectId]int)
+	b.pending = 0
+}
+//-----------------------------------------------------------------------------
+// 4. bulkInsert: Internal use
+// Inserts docs into table using Mongo's Bulk API, chunked to BUILDER_BULK_CHUNK
+// documents per round-trip.
+func bulkInsert(table *mgo.Collection, docs []interface{}) {
+	for i := 0; i < len(docs); i += BUILDER_BULK_CHUNK {
+		end := i + BUILDER_BULK_CHUNK
+		if end > len(docs) {
+			end = len(docs)
+		}
+
+		bulk := table.Bulk()
+		bulk.Insert(docs[i:end]...)
+		bulk.Run()
+	}
+}
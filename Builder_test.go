@@ -0,0 +1,91 @@
+package searcher
+
+import(
+	"testing"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// TestBuilderAddAccumulatesAcrossMultipleBlogs exercises the in-memory half
+// of Builder (everything Add does) without touching Mongo: Commit needs a
+// live blogTable/weightTable/termTable and so isn't exercised here. A term
+// appearing in more than one blog should accumulate into a single
+// PostingList with one Posting per blog and a document frequency equal to
+// the number of distinct blogs it appeared in.
+func TestBuilderAddAccumulatesAcrossMultipleBlogs(t *testing.T) {
+	data := &DBConnector{analyzer : whitespaceAnalyzer{}}
+	builder := NewBuilder(data)
+
+	first := Blog{Blog_id : bson.NewObjectId(), Title : "spicy soup", Content : "hot and sour"}
+	second := Blog{Blog_id : bson.NewObjectId(), Title : "thai curry", Content : "coconut and sour"}
+
+	builder.Add(first)
+	builder.Add(second)
+
+	if builder.docCount != 2 {
+		t.Fatalf("got docCount %d, want 2", builder.docCount)
+	}
+	if builder.pending != 2 {
+		t.Fatalf("got pending %d, want 2", builder.pending)
+	}
+
+	// "spicy" only appears in the first blog: df 1, one posting
+	spicy, found := builder.postings["spicy"]
+	if !found {
+		t.Fatal("expected a posting list for \"spicy\"")
+	}
+	if spicy.documentFrequency != 1 || len(spicy.postings) != 1 {
+		t.Fatalf("got df %d with %d postings for \"spicy\", want df 1 with 1 posting", spicy.documentFrequency, len(spicy.postings))
+	}
+
+	// "sour" appears in both blogs: df 2, one posting per blog
+	sour, found := builder.postings["sour"]
+	if !found {
+		t.Fatal("expected a posting list for \"sour\"")
+	}
+	if sour.documentFrequency != 2 || len(sour.postings) != 2 {
+		t.Fatalf("got df %d with %d postings for \"sour\", want df 2 with 2 postings", sour.documentFrequency, len(sour.postings))
+	}
+
+	seen := map[bson.ObjectId]bool{}
+	for _, posting := range sour.postings {
+		seen[posting.blogID] = true
+	}
+	if !seen[first.Blog_id] || !seen[second.Blog_id] {
+		t.Fatalf("expected postings for both blogs, got %v", sour.postings)
+	}
+
+	// Each blog should have its own norm and length recorded
+	if len(builder.norms) != 2 || len(builder.lengths) != 2 {
+		t.Fatalf("got %d norms and %d lengths, want 2 of each", len(builder.norms), len(builder.lengths))
+	}
+}
+
+// TestBuilderAddTracksPositionsPerField confirms a term occurring in more
+// than one field (e.g. a blog titled "soup" whose content also says "soup")
+// keeps a separate position list per field, rather than being tagged with
+// only the first field it was seen in — which would make it invisible to a
+// "content:" field query and let title/content positions collide as
+// adjacent in phrase matching.
+func TestBuilderAddTracksPositionsPerField(t *testing.T) {
+	data := &DBConnector{analyzer : whitespaceAnalyzer{}}
+	builder := NewBuilder(data)
+
+	blog := Blog{Blog_id : bson.NewObjectId(), Title : "soup", Content : "soup is hot"}
+	builder.Add(blog)
+
+	soup, found := builder.postings["soup"]
+	if !found || len(soup.postings) != 1 {
+		t.Fatalf("expected exactly one posting for \"soup\", got %+v", soup)
+	}
+	posting := soup.postings[0]
+
+	if posting.occurrences != 2 {
+		t.Fatalf("got %d occurrences, want 2 (once in title, once in content)", posting.occurrences)
+	}
+	if len(posting.positions[FIELD_TITLE]) != 1 || posting.positions[FIELD_TITLE][0] != 0 {
+		t.Fatalf("got title positions %v, want [0]", posting.positions[FIELD_TITLE])
+	}
+	if len(posting.positions[FIELD_CONTENT]) != 1 || posting.positions[FIELD_CONTENT][0] != 0 {
+		t.Fatalf("got content positions %v, want [0]", posting.positions[FIELD_CONTENT])
+	}
+}
@@ -19,6 +19,13 @@ type (
 		Blog_id				bson.ObjectId	`bson:"blog_id,omitempty"`
 		Tf					float64			`json:"tf"`
 		Tf_Idf				float64			`json:"tf_idf"`
+		Occurrences			int				`json:"occurrences"`
+		// Positions is keyed by field (title/content/tag) so phrase-adjacency
+		// checks and field-qualified Boolean queries (e.g. "content:") only
+		// ever look within the field they're scoped to, instead of a single
+		// flat list that would let a term's title occurrence and content
+		// occurrence collide as "adjacent".
+		Positions			map[string][]int	`json:"positions,omitempty"`
 	}
 
 	Term_Weight struct {
@@ -26,4 +33,11 @@ type (
 		Total_blogs			int 			`json:"total_blogs"`
 		Idf					float64			`json:"idf"`
 	}
+
+	// Doc_Stats records per-document statistics needed by scorers which,
+	// unlike cosine/TF-IDF, care about document length (e.g. BM25).
+	Doc_Stats struct {
+		Blog_id				bson.ObjectId	`bson:"blog_id,omitempty"`
+		Length				int				`json:"length"`
+	}
 )
\ No newline at end of file
@@ -15,32 +15,71 @@ const(
 	TERM_TABLE_NAME = "inverted_index"
 	WEIGHT_TABLE_NAME = "term_weight"
 	BLOG_TABLE_NAME = "post"
+	DOC_STATS_TABLE_NAME = "doc_stats"
+)
+
+// Field names tagged onto Inverted_Index entries so Boolean field queries
+// (e.g. "title:") know which part of the blog a term came from.
+const(
+	FIELD_TITLE = "title"
+	FIELD_CONTENT = "content"
+	FIELD_TAG = "tag"
+)
+
+// BM25 tuning constants. k1 controls term-frequency saturation, b controls
+// document-length normalization, and epsilon floors the IDF so very common
+// terms (n_t close to N) cannot pull a document's score negative.
+const(
+	BM25_K1 = 1.5
+	BM25_B = 0.75
+	BM25_EPSILON = 0.25
+)
+
+// Scorer selects which ranking formula DBConnector.rank uses.
+type Scorer int
+
+const(
+	CosineScorer Scorer = iota
+	BM25Scorer
 )
 
 //-----------------------------------------------------------------------------
 // DBConnector Structure
 //-----------------------------------------------------------------------------
-// Contains connectors to 3 essential database collections in MongoDB
+// Contains connectors to 4 essential database collections in MongoDB
 // and a Thai language tokenizer
 //-----------------------------------------------------------------------------
 type DBConnector struct {
 	termTable *mgo.Collection
 	weightTable *mgo.Collection
 	blogTable *mgo.Collection
+	docStatsTable *mgo.Collection
 	tokenizer *tokenizer.LongLexto
+	scorer Scorer
+	analyzer Analyzer
+}
+// termOccurrence accumulates, per token encountered while indexing a blog,
+// its raw occurrence count across every field, and the token positions it
+// was found at within each field it appeared in (title/content/tag), kept
+// separate per field so phrase-adjacency checks never span a field boundary
+// and field-qualified Boolean queries can tell which fields a term actually
+// occurred in.
+type termOccurrence struct {
+	count int
+	positions map[string][]int
 }
 //-----------------------------------------------------------------------------
 // DBConnector Private Mathematic Functions
 //-----------------------------------------------------------------------------
 // 1. euclidean_norm: Mathematical function
 // Calculates Euclidean Normal Value - Square Rooot of sum of occurences of each term squared
-func euclidean_norm(frequency map[tokenizer.Token]int) float64 {
+func euclidean_norm(frequency map[string]*termOccurrence) float64 {
 	result := 0.0
 
 	// 1. Gain access to each occurence
 	for _, value := range frequency {
 		// 2. Sum up sqaure power of each value
-		result += float64(value * value)
+		result += float64(value.count * value.count)
 	}
 
 	// 3. Return results
@@ -60,6 +99,30 @@ func inverse_document_freq(termBlogs int, totalBlogs int) float64 {
 	return math.Log(float64(totalBlogs) / float64(termBlogs))
 }
 //-----------------------------------------------------------------------------
+// 3.1 bm25_idf: Mathematical function
+// Calculates the Okapi BM25 Inverse Document Frequency - Logarithm of one
+// plus (total documents minus documents containing the term, smoothed by
+// 0.5) divided by (documents containing the term, also smoothed), floored at BM25_EPSILON
+// so common terms never drag a document's score below zero.
+func bm25_idf(termBlogs int, totalBlogs int) float64 {
+	idf := math.Log((float64(totalBlogs)-float64(termBlogs)+0.5)/(float64(termBlogs)+0.5) + 1)
+	if idf < BM25_EPSILON {
+		return BM25_EPSILON
+	}
+	return idf
+}
+//-----------------------------------------------------------------------------
+// 3.2 bm25_term_score: Mathematical function
+// Calculates a single term's BM25 contribution to a document's score given
+// its raw occurrences, the document's length, and the collection's average
+// document length.
+func bm25_term_score(idf float64, occurrences int, docLength int, avgdl float64) float64 {
+	f := float64(occurrences)
+	numerator := f * (BM25_K1 + 1)
+	denominator := f + BM25_K1*(1-BM25_B+BM25_B*(float64(docLength)/avgdl))
+	return idf * (numerator / denominator)
+}
+//-----------------------------------------------------------------------------
 // 4. tf_idf: Mathematical function
 // Calculates Term Frequency Value multiply by Inverse Document Frequency Value
 func tf_idf(tf float64, idf float64) float64 {
@@ -69,30 +132,42 @@ func tf_idf(tf float64, idf float64) float64 {
 // DBConnector Private Methods
 //-----------------------------------------------------------------------------
 // 1. count_occurences: Internal use
-// Tokenizes the text in the blog content and counts occurences of each token
-func (data *DBConnector) count_occurences(content string) map[tokenizer.Token]int {
-	// 1. Create a map of a token string to its occurences
-	frequency := make(map[tokenizer.Token]int)
-	// 2. Split text into tokens
-	data.tokenizer.SetText(content)
+// Runs each field of the blog (title, content, tags) through the
+// DBConnector's analyzer, counting occurrences of each resulting term and
+// the positions it was found at within each field. Positions are tracked
+// per field (each field keeps its own running counter) rather than as one
+// counter across the whole document, so a term's title occurrence is never
+// treated as adjacent to its content occurrence, and field-qualified
+// Boolean queries (e.g. "content:") can be answered from exactly the fields
+// a term occurred in.
+func (data *DBConnector) count_occurences(blog Blog) map[string]*termOccurrence {
+	// 1. Create a map of a term to its occurrence info
+	frequency := make(map[string]*termOccurrence)
+	fieldPosition := make(map[string]int)
 
-	// 3. Count occurences of each term
-	for data.tokenizer.HasNext() {
-		// 3.1 Get the next term
-		token := data.tokenizer.Next()
-		// 3.2 Ignore spaces
-		if token.IsSpace() || token.IsHTML() {
-			continue
-		// 3.3 If term exists before, increase the counting by 1
-		} else if _, found := frequency[token]; found {
-			frequency[token] += 1
-		// 3.4 If the term does not exist, then it is found
-		// for the first time. The term will be new key
-		} else {
-			frequency[token] = 1
+	// 2. index analyzes a single field and folds its terms into frequency
+	index := func(text string, field string) {
+		for _, term := range data.analyzer.Analyze(text) {
+			// 2.1 If the term does not exist, then it is found for the
+			// first time
+			if _, found := frequency[term]; !found {
+				frequency[term] = &termOccurrence{positions : make(map[string][]int)}
+			}
+			// 2.2 Increase the counting by 1 and record its position
+			// within this field
+			frequency[term].count += 1
+			frequency[term].positions[field] = append(frequency[term].positions[field], fieldPosition[field])
+			fieldPosition[field] += 1
 		}
 	}
 
+	// 3. Index each field in turn
+	index(blog.Title, FIELD_TITLE)
+	index(blog.Content, FIELD_CONTENT)
+	for _, tag := range blog.Tags {
+		index(tag, FIELD_TAG)
+	}
+
 	// 4. Return results
 	return frequency
 }
@@ -100,7 +175,7 @@ func (data *DBConnector) count_occurences(content string) map[tokenizer.Token]in
 // 2. updateIDF: Internal use
 // Calculates the weight of each term for further searching mechanisms.
 // Either register new terms or update existing terms to Term Weight Collection.
-func (data *DBConnector) updateIDF(frequency map[tokenizer.Token]int) {
+func (data *DBConnector) updateIDF(frequency map[string]*termOccurrence) {
 	// 1. Count all blogs in the database
 
 	// fmt.Println(data.blogTable)
@@ -112,12 +187,12 @@ func (data *DBConnector) updateIDF(frequency map[tokenizer.Token]int) {
 	weightRow := Term_Weight{}
 	for key, _ := range frequency {
 		// 3. Check if the term exists
-		_ = data.weightTable.Find(bson.M{"term": key.GetText()}).One(&weightRow)
+		_ = data.weightTable.Find(bson.M{"term": key}).One(&weightRow)
 		// 4. If the term does not exist, add the term as new entry
 		if (weightRow.Term == "") {
 			data.weightTable.Insert(
 				&Term_Weight{
-					Term : key.GetText(),
+					Term : key,
 					Idf : inverse_document_freq(1, totalBlogs),
 					Total_blogs : 1,
 					})
@@ -125,7 +200,7 @@ func (data *DBConnector) updateIDF(frequency map[tokenizer.Token]int) {
 		} else {
 			termBlogs := weightRow.Total_blogs + 1
 			data.weightTable.Update(
-				bson.M{"term" : key.GetText()},
+				bson.M{"term" : key},
 				bson.M{
 					"set": bson.M{
 						"idf" : inverse_document_freq(termBlogs, totalBlogs),
@@ -139,26 +214,65 @@ func (data *DBConnector) updateIDF(frequency map[tokenizer.Token]int) {
 // 6. newIndexes: Internal use
 // Add terms as inverted indexes to Inverted Index Collection.
 // Records documents containing terms along with its Tf_Idf value
-func (data *DBConnector) newIndexes(frequency map[tokenizer.Token]int, blogID bson.ObjectId, norm float64) {
+func (data *DBConnector) newIndexes(frequency map[string]*termOccurrence, blogID bson.ObjectId, norm float64) {
 	idf := Term_Weight{}
 
 	// 1. Read each token
 	for key, value := range frequency {
 		// 2. Retrieve IDF value of the term
-		_ = data.weightTable.Find(bson.M{"term" : key.GetText()}).One(&idf)
+		_ = data.weightTable.Find(bson.M{"term" : key}).One(&idf)
 		// 3. Calculate the TF value of the term
-		tf := term_freq(value, norm)
+		tf := term_freq(value.count, norm)
 		// 4. Add all data as new inverted index entry
 		data.termTable.Insert(
 			&Inverted_Index{
-				Term : key.GetText(),
+				Term : key,
 				Blog_id : blogID,
 				Tf : tf,
 				Tf_Idf : tf_idf(tf, idf.Idf),
+				Occurrences : value.count,
+				Positions : value.positions,
 				})
 	}
 }
 //-----------------------------------------------------------------------------
+// 6.1 updateDocStats: Internal use
+// Records the document's length (total term occurrences) so length-aware
+// scorers such as BM25 can normalize against it and the collection average.
+func (data *DBConnector) updateDocStats(frequency map[string]*termOccurrence, blogID bson.ObjectId) {
+	length := 0
+	for _, value := range frequency {
+		length += value.count
+	}
+
+	stats := Doc_Stats{}
+	err := data.docStatsTable.Find(bson.M{"blog_id" : blogID}).One(&stats)
+	if err != nil {
+		data.docStatsTable.Insert(&Doc_Stats{Blog_id : blogID, Length : length})
+	} else {
+		data.docStatsTable.Update(
+			bson.M{"blog_id" : blogID},
+			bson.M{"$set" : bson.M{"length" : length}})
+	}
+}
+//-----------------------------------------------------------------------------
+// 6.2 avgDocLength: Internal use
+// Calculates the collection's running average document length, used by the
+// BM25 length-normalization term.
+func (data *DBConnector) avgDocLength() float64 {
+	stats := []Doc_Stats{}
+	data.docStatsTable.Find(nil).All(&stats)
+	if len(stats) == 0 {
+		return 0
+	}
+
+	total := 0
+	for _, s := range stats {
+		total += s.Length
+	}
+	return float64(total) / float64(len(stats))
+}
+//-----------------------------------------------------------------------------
 // DBConnector Methods
 //-----------------------------------------------------------------------------
 // 1. AddIndexes:
@@ -167,20 +281,19 @@ func (data *DBConnector) newIndexes(frequency map[tokenizer.Token]int, blogID bs
 // III - Find Euclidean Normal based on term occurence.
 // IV  - Update Inverted Document Frequency Value of each term.
 // V   - Add each term as new entry in Inverted Index Collection.
+// Intended for single-document updates; bulk ingestion should go through a
+// Builder instead, since each call here costs several Mongo round-trips.
 func (data *DBConnector) AddIndexes(blog Blog) {
-	// 1. Assemble blog contents for tokenizing
-	content := blog.Title + " " + blog.Content
-	for _, i := range blog.Tags {
-		content += " " + i
-	}
-	// 2. Count all occurences of each term
-	frequency := data.count_occurences(content)
-	// 3. Calculate Euclidean Normal Value for computing Term Frequency later
+	// 1. Tokenize each field and count occurrences, positions, and field of each term
+	frequency := data.count_occurences(blog)
+	// 2. Calculate Euclidean Normal Value for computing Term Frequency later
 	norm := euclidean_norm(frequency)
-	// 4. Update each term's weight in Term Weight Table
+	// 3. Update each term's weight in Term Weight Table
 	data.updateIDF(frequency)
-	// 5. Add each term in the blog to Inverted Index Table
+	// 4. Add each term in the blog to Inverted Index Table
 	data.newIndexes(frequency, blog.Blog_id, norm)
+	// 5. Record the document's length for length-aware scorers (e.g. BM25)
+	data.updateDocStats(frequency, blog.Blog_id)
 }
 //-----------------------------------------------------------------------------
 // 2. Remove Indexes is accidently implemented due to miscommunication.
@@ -214,21 +327,32 @@ func connect() []*mgo.Collection {
 	termTable := database.C(TERM_TABLE_NAME)
 	weightTable := database.C(WEIGHT_TABLE_NAME)
 	blogTable := database.C(BLOG_TABLE_NAME)
+	docStatsTable := database.C(DOC_STATS_TABLE_NAME)
 
-	return []*mgo.Collection{termTable, weightTable, blogTable}
+	return []*mgo.Collection{termTable, weightTable, blogTable, docStatsTable}
 }
 //-----------------------------------------------------------------------------
 // 2. Setup:
-// Initiates the DBConnector struct for both indexing and searching.
-func Setup(dictionary string) *DBConnector {
+// Initiates the DBConnector struct for both indexing and searching. filters
+// is optional; when omitted it defaults to DefaultFilters() (lowercase plus a
+// StopWordFilter with an empty word list).
+func Setup(dictionary string, filters ...TokenFilter) *DBConnector {
 	var tables []*mgo.Collection = connect()
 	// fmt.Println(tables)
-	return &DBConnector{tables[0], tables[1], tables[2], tokenizer.Initialize(dictionary)}
+	if len(filters) == 0 {
+		filters = DefaultFilters()
+	}
+	tok := tokenizer.Initialize(dictionary)
+	return &DBConnector{tables[0], tables[1], tables[2], tables[3], tok, CosineScorer, newAnalyzer(tok, filters)}
 }
 
 
-func Setup_db_session( dictionary string, invertedDB *mgo.Collection,term_weightDB *mgo.Collection,blogDB *mgo.Collection) *DBConnector{
-	return &DBConnector{invertedDB, term_weightDB, blogDB, tokenizer.Initialize(dictionary)}
+func Setup_db_session( dictionary string, invertedDB *mgo.Collection,term_weightDB *mgo.Collection,blogDB *mgo.Collection,docStatsDB *mgo.Collection, filters ...TokenFilter) *DBConnector{
+	if len(filters) == 0 {
+		filters = DefaultFilters()
+	}
+	tok := tokenizer.Initialize(dictionary)
+	return &DBConnector{invertedDB, term_weightDB, blogDB, docStatsDB, tok, CosineScorer, newAnalyzer(tok, filters)}
 	// blogs := []Blog{}
 	// err := blogDB.Find(nil).All(&blogs)
 	// check(err)
@@ -236,12 +360,36 @@ func Setup_db_session( dictionary string, invertedDB *mgo.Collection,term_weight
 	// fmt.Println(blogs)
 
 }
+//-----------------------------------------------------------------------------
+// 3. SetScorer:
+// Switches the ranking formula used by Query/rank between the default
+// cosine TF-IDF similarity and Okapi BM25.
+func (data *DBConnector) SetScorer(scorer Scorer) {
+	data.scorer = scorer
+}
+//-----------------------------------------------------------------------------
+// 4. SetFilters:
+// Replaces the analyzer's TokenFilter chain. Existing indexes built under
+// the previous chain become stale and should be rebuilt with Reanalyze().
+func (data *DBConnector) SetFilters(filters []TokenFilter) {
+	data.analyzer = newAnalyzer(data.tokenizer, filters)
+}
+// Indexes every blog in the post collection. Goes through a Builder rather
+// than calling AddIndexes per blog, so bulk/initial indexing costs O(N)
+// Mongo round-trips instead of O(N*T).
 func (data *DBConnector) Create_index_forAllBlogs() {
-	blogs := []Blog{}
-	data.blogTable.Find(nil).All(&blogs)
-	for _, blog := range blogs {
-		data.AddIndexes(blog)
-	}
+	NewBuilder(data).BuildAll()
+}
+//-----------------------------------------------------------------------------
+// 5. Reanalyze:
+// Rebuilds inverted_index, term_weight, and doc_stats from post using the
+// DBConnector's current analyzer. Needed after SetFilters, since prior
+// indexes were built from a different token stream and are now stale.
+func (data *DBConnector) Reanalyze() {
+	data.termTable.RemoveAll(nil)
+	data.weightTable.RemoveAll(nil)
+	data.docStatsTable.RemoveAll(nil)
+	data.Create_index_forAllBlogs()
 }
 // func (data *DBConnector) Test2() {
 
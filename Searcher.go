@@ -4,7 +4,6 @@ import(
 	"math"
 	"sort"
 	"gopkg.in/mgo.v2/bson"
-	"fmt"
 )
 
 //-----------------------------------------------------------------------------
@@ -35,7 +34,10 @@ func (slice Similarities) Swap(i, j int) {
 }
 
 func (slice Similarities) Less(i, j int) bool {
-	return slice[i].cosine < slice[j].cosine
+	// Descending: sort.Sort(Similarities(...)) should yield most-similar
+	// first, matching every caller's doc comment. It previously read "<"
+	// here, which actually sorted least-similar first.
+	return slice[i].cosine > slice[j].cosine
 }
 //-----------------------------------------------------------------------------
 // Retrieving Functions
@@ -45,35 +47,24 @@ func (slice Similarities) Less(i, j int) bool {
 // II  - Fetch all blogs containing terms which are part of the query.
 // III - Perform ranking to order by most relevant results.
 func (data *DBConnector) Query(keyword string) []bson.ObjectId {
-	// 1. Tokenize search text into keywords
-	data.tokenizer.SetText(keyword)
+	// 1. Analyze search text into distinct terms, using the same analyzer
+	// AddIndexes used, so query-time terms match index-time terms
 	termFound := make(map[string]bool)
 	terms := make([]string, 0)
 
-	// 2. Read each token
-	for data.tokenizer.HasNext() {
-		// 3. Get next token
-		token := data.tokenizer.Next()
-		// 4. Ignore whitespace tokens or terms which have already been found
-		if _, found := termFound[token.GetText()]; found {
+	for _, term := range data.analyzer.Analyze(keyword) {
+		if termFound[term] {
 			continue
-		} else if token.IsSpace() {
-			continue
-		// 5. Check if the term is found for the first time
-		} else {
-			// 6. Mark the term as found
-			termFound[token.GetText()] = true
-			// 7. Add the term to list
-			terms = append(terms, token.GetText())
 		}
+		termFound[term] = true
+		terms = append(terms, term)
 	}
-	fmt.Println(terms)
-	// 8. Fetch all blogs containing any words from the query text
+
+	// 2. Fetch all blogs containing any words from the query text
 	var blogs []bson.ObjectId = data.retrieve(terms)
-	fmt.Println(blogs)
-	// 9. Rank all blogs and sort them according to similarity
+	// 3. Rank all blogs and sort them according to similarity
 	blogs = data.rank(terms, blogs)
-	// 10. Return results
+	// 4. Return results
 	return blogs
 }
 //-----------------------------------------------------------------------------
@@ -100,6 +91,11 @@ func (data *DBConnector) retrieve(terms []string) []bson.ObjectId {
 // V   - Sort the blogs according to its cosine similarity value in descending order.
 // VI  - Return only a list of BlogIDs in the same order sorted.
 func (data *DBConnector) rank(terms []string, blogs []bson.ObjectId) []bson.ObjectId {
+	// 0. Delegate to the BM25 scorer if it has been selected via SetScorer
+	if data.scorer == BM25Scorer {
+		return data.rankBM25(terms, blogs)
+	}
+
 	// 1. Retireve Inverse Document Frequency Value for each query term
 	query := data.queryRank(terms)
 	blogTerms := []Inverted_Index{}
@@ -108,7 +104,7 @@ func (data *DBConnector) rank(terms []string, blogs []bson.ObjectId) []bson.Obje
 	// 2. Read in each search results
 	for _, i := range blogs {
 		// 3. Retreive all terms in the blog which is also in the query
-		data.termTable.Find(bson.M{"$in" : bson.M{"term" : terms}, "blog_id" : i}).All(&blogTerms)
+		data.termTable.Find(bson.M{"term" : bson.M{"$in" : terms}, "blog_id" : i}).All(&blogTerms)
 		// 4. Match each terms retrieved with its Tf_Idf
 		blog := arrangeTerms(blogTerms)
 		// 5. Find the cosine similarity between query and the blog
@@ -128,6 +124,56 @@ func (data *DBConnector) rank(terms []string, blogs []bson.ObjectId) []bson.Obje
 	return blogIDs
 }
 //-----------------------------------------------------------------------------
+// 1.1 QueryBM25:
+// Same as Query, but forces BM25 scoring for this single call regardless of
+// the DBConnector's configured scorer, leaving the configured scorer intact
+// for subsequent calls.
+func (data *DBConnector) QueryBM25(keyword string) []bson.ObjectId {
+	previous := data.scorer
+	data.SetScorer(BM25Scorer)
+	defer data.SetScorer(previous)
+	return data.Query(keyword)
+}
+//-----------------------------------------------------------------------------
+// 1.2 rankBM25: Internal use
+// Ranks blogs using Okapi BM25 instead of cosine similarity.
+func (data *DBConnector) rankBM25(terms []string, blogs []bson.ObjectId) []bson.ObjectId {
+	// 1. Total blogs in the collection, needed for IDF
+	totalBlogs, _ := data.blogTable.Find(nil).Count()
+	// 2. Collection average document length, needed for length normalization
+	avgdl := data.avgDocLength()
+	results := []Similarity{}
+
+	// 3. Score each candidate blog against the query terms
+	for _, i := range blogs {
+		blogTerms := []Inverted_Index{}
+		data.termTable.Find(bson.M{"term" : bson.M{"$in" : terms}, "blog_id" : i}).All(&blogTerms)
+
+		stats := Doc_Stats{}
+		data.docStatsTable.Find(bson.M{"blog_id" : i}).One(&stats)
+
+		score := 0.0
+		for _, t := range blogTerms {
+			weight := Term_Weight{}
+			data.weightTable.Find(bson.M{"term" : t.Term}).One(&weight)
+			idf := bm25_idf(weight.Total_blogs, totalBlogs)
+			score += bm25_term_score(idf, t.Occurrences, stats.Length, avgdl)
+		}
+
+		results = append(results, Similarity{blog : i, cosine : score})
+	}
+
+	// 4. Sort descending by score (highest first)
+	sort.Sort(Similarities(results))
+
+	// 5. Return only the list of blog IDs
+	blogIDs := []bson.ObjectId{}
+	for _, i := range results {
+		blogIDs = append(blogIDs, i.blog)
+	}
+	return blogIDs
+}
+//-----------------------------------------------------------------------------
 // 2. queryRank: Internal use
 // Maps distinct terms in query to its corresponding Inverse Document
 // Frequency Value.
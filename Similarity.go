@@ -0,0 +1,154 @@
+package searcher
+
+import(
+	"container/heap"
+	"sort"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// SIMILAR_QUERY_TERMS is the number of a blog's highest-weighted terms used
+// as a synthetic query when finding similar blogs.
+const SIMILAR_QUERY_TERMS = 20
+
+//-----------------------------------------------------------------------------
+// "More Like This" / Document Similarity
+//-----------------------------------------------------------------------------
+// 1. SimilarBlogs:
+// I   - Build the source blog's TF-IDF vector from its Inverted_Index rows.
+// II  - Take its top-N highest-weight terms as a synthetic query.
+// III - Fetch candidate blogs containing any of those terms.
+// IV  - Score every candidate against the source with cosine similarity.
+// V   - Return only the topK most similar blogs, without sorting the rest.
+func (data *DBConnector) SimilarBlogs(id bson.ObjectId, topK int) []bson.ObjectId {
+	source := data.blogVector(id)
+	if len(source) == 0 {
+		return []bson.ObjectId{}
+	}
+
+	query := topWeightedTerms(source, SIMILAR_QUERY_TERMS)
+	candidateIDs := data.retrieve(query)
+
+	candidates := []Similarity{}
+	for _, candidateID := range candidateIDs {
+		if candidateID == id {
+			continue
+		}
+		candidate := data.blogVector(candidateID)
+		candidates = append(candidates, Similarity{blog : candidateID, cosine : cosineSimilarity(source, candidate)})
+	}
+
+	return topKSimilar(candidates, topK)
+}
+//-----------------------------------------------------------------------------
+// 2. SimilarityMatrix:
+// Computes the pairwise cosine similarity between every blog in ids, for
+// small batches (e.g. clustering related posts). Each blog's term vector
+// and magnitude are loaded/computed once and reused across every comparison
+// involving that blog, rather than recomputed per pair.
+func (data *DBConnector) SimilarityMatrix(ids []bson.ObjectId) [][]float64 {
+	vectors := make([]map[string]float64, len(ids))
+	magnitudes := make([]float64, len(ids))
+	for i, id := range ids {
+		vectors[i] = data.blogVector(id)
+		magnitudes[i] = magnitude(vectors[i])
+	}
+
+	matrix := make([][]float64, len(ids))
+	for i := range ids {
+		matrix[i] = make([]float64, len(ids))
+	}
+
+	for i := 0; i < len(ids); i++ {
+		matrix[i][i] = 1
+		for j := i + 1; j < len(ids); j++ {
+			similarity := crossProduct(vectors[i], vectors[j]) / (magnitudes[i] * magnitudes[j])
+			matrix[i][j] = similarity
+			matrix[j][i] = similarity
+		}
+	}
+	return matrix
+}
+//-----------------------------------------------------------------------------
+// 3. blogVector: Internal use
+// Builds a blog's TF-IDF vector (term -> Tf_Idf) from its Inverted_Index rows.
+func (data *DBConnector) blogVector(id bson.ObjectId) map[string]float64 {
+	rows := []Inverted_Index{}
+	data.termTable.Find(bson.M{"blog_id" : id}).All(&rows)
+	return arrangeTerms(rows)
+}
+//-----------------------------------------------------------------------------
+// 4. topWeightedTerms: Internal use
+// Returns up to n terms from vector with the highest weight, used to build a
+// synthetic "more like this" query from a blog's own TF-IDF vector.
+func topWeightedTerms(vector map[string]float64, n int) []string {
+	type weightedTerm struct {
+		term string
+		weight float64
+	}
+
+	terms := make([]weightedTerm, 0, len(vector))
+	for term, weight := range vector {
+		terms = append(terms, weightedTerm{term, weight})
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		return terms[i].weight > terms[j].weight
+	})
+
+	if n > len(terms) {
+		n = len(terms)
+	}
+	result := make([]string, n)
+	for i := 0; i < n; i++ {
+		result[i] = terms[i].term
+	}
+	return result
+}
+//-----------------------------------------------------------------------------
+// similarityHeap: Internal use
+// A min-heap of Similarity values, letting topKSimilar track only the
+// current top-K matches while scanning candidates, instead of sorting the
+// full candidate set just to take its head.
+//-----------------------------------------------------------------------------
+type similarityHeap []Similarity
+
+func (h similarityHeap) Len() int { return len(h) }
+func (h similarityHeap) Less(i, j int) bool { return h[i].cosine < h[j].cosine }
+func (h similarityHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *similarityHeap) Push(x interface{}) {
+	*h = append(*h, x.(Similarity))
+}
+
+func (h *similarityHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+//-----------------------------------------------------------------------------
+// 5. topKSimilar: Internal use
+// Keeps only the topK highest-cosine Similarity values from candidates,
+// using a bounded min-heap, and returns their blog IDs in descending order
+// of similarity.
+func topKSimilar(candidates []Similarity, topK int) []bson.ObjectId {
+	h := &similarityHeap{}
+	heap.Init(h)
+
+	for _, candidate := range candidates {
+		if h.Len() < topK {
+			heap.Push(h, candidate)
+		} else if h.Len() > 0 && candidate.cosine > (*h)[0].cosine {
+			heap.Pop(h)
+			heap.Push(h, candidate)
+		}
+	}
+
+	// The heap drains in ascending order; reverse it into descending
+	// (most-similar first) order as we pop.
+	ordered := make([]bson.ObjectId, h.Len())
+	for i := len(ordered) - 1; i >= 0; i-- {
+		ordered[i] = heap.Pop(h).(Similarity).blog
+	}
+	return ordered
+}